@@ -0,0 +1,144 @@
+// geo.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// weatherGeoKey is the Redis key backing the shared geo index of
+// registered locations, kept separate from the per-location weather
+// cache entries so the two can be scanned and expired independently.
+const weatherGeoKey = "weather:geo"
+
+// LocationSet resolves named locations to coordinates and back using
+// Redis GEOADD/GEOSEARCH, so nearby lookups can find the closest
+// registered location without scanning every cached key.
+type LocationSet struct{}
+
+func newLocationSet() *LocationSet {
+	return &LocationSet{}
+}
+
+// Add registers a named location at the given coordinates.
+func (s *LocationSet) Add(ctx context.Context, name string, lat, lon float64) error {
+	client := currentRedisClient()
+	if client == nil {
+		return errRedisUnavailable
+	}
+	cmd := client.B().Geoadd().Key(weatherGeoKey).LongitudeLatitudeMember().LongitudeLatitudeMember(lon, lat, name).Build()
+	return client.Do(ctx, cmd).Error()
+}
+
+// Nearby returns registered locations within radiusKm of (lat, lon),
+// closest first.
+func (s *LocationSet) Nearby(ctx context.Context, lat, lon, radiusKm float64) ([]string, error) {
+	client := currentRedisClient()
+	if client == nil {
+		return nil, errRedisUnavailable
+	}
+	cmd := client.B().Geosearch().Key(weatherGeoKey).Fromlonlat(lon, lat).Byradius(radiusKm).Km().Asc().Build()
+	return client.Do(ctx, cmd).AsStrSlice()
+}
+
+var locationSet = newLocationSet()
+
+type registerLocationRequest struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+// registerLocationHandler registers a named location at a set of
+// coordinates in the shared geo index.
+func registerLocationHandler(w http.ResponseWriter, r *http.Request) {
+	var req registerLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if err := locationSet.Add(ctx, req.Name, req.Lat, req.Lon); err != nil {
+		log.Printf("Error registering location %s: %v", req.Name, err)
+		http.Error(w, "Error registering location", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Registered location %s at (%f, %f)", req.Name, req.Lat, req.Lon)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "registered", "name": req.Name})
+}
+
+// nearbyWeatherHandler finds the closest registered location to the given
+// coordinates and returns its weather, serving from cache when possible
+// before falling back to an upstream fetch.
+func nearbyWeatherHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(query.Get("lon"), 64)
+	if latErr != nil || lonErr != nil {
+		http.Error(w, "lat and lon query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	radiusKm := 10.0
+	if v := query.Get("radius"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed > 0 {
+			radiusKm = parsed
+		}
+	}
+
+	ctx := r.Context()
+	locations, err := locationSet.Nearby(ctx, lat, lon, radiusKm)
+	if err != nil {
+		log.Printf("Error searching nearby locations: %v", err)
+		http.Error(w, "Error searching nearby locations", http.StatusInternalServerError)
+		return
+	}
+	if len(locations) == 0 {
+		http.Error(w, "No registered locations within radius", http.StatusNotFound)
+		return
+	}
+
+	location := locations[0]
+	log.Printf("Nearest registered location to (%f, %f) is %s", lat, lon, location)
+
+	cachedData, err := weatherCache.Get(ctx, location)
+	recordCacheResult(location, err == nil)
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(cachedData))
+		return
+	}
+
+	weatherData, err := weather.FetchWeather(ctx, location)
+	if err != nil {
+		log.Printf("Error fetching weather data for %s: %v", location, err)
+		http.Error(w, "Error fetching weather data", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(weatherData)
+	if err != nil {
+		log.Printf("Error marshaling weather data: %v", err)
+		http.Error(w, "Error processing weather data", http.StatusInternalServerError)
+		return
+	}
+
+	if err := weatherCache.Put(ctx, location, jsonData, 5*time.Minute); err != nil {
+		log.Printf("Error caching weather data: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
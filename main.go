@@ -4,14 +4,13 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
+	"github.com/redis/rueidis"
 )
 
 // WeatherData represents the weather information for a location
@@ -26,16 +25,19 @@ type WeatherData struct {
 
 // Config holds the application configuration
 type Config struct {
-	WeatherAPIKey string
-	RedisHost     string
-	RedisPort     string
-	Port          string
-	Environment   string
+	WeatherAPIKey   string
+	WeatherProvider string
+	RedisHost       string
+	RedisPort       string
+	Port            string
+	Environment     string
 }
 
 var (
-	redisClient *redis.Client
-	config      Config
+	redisClient  rueidis.Client
+	weatherCache *WeatherCache
+	config       Config
+	weather      WeatherProvider
 )
 
 func init() {
@@ -44,11 +46,12 @@ func init() {
 
 	// Load configuration from environment variables
 	config = Config{
-		WeatherAPIKey: os.Getenv("WEATHER_API_KEY"),
-		RedisHost:     os.Getenv("REDIS_HOST"),
-		RedisPort:     os.Getenv("REDIS_PORT"),
-		Port:          os.Getenv("PORT"),
-		Environment:   os.Getenv("ENVIRONMENT"),
+		WeatherAPIKey:   os.Getenv("WEATHER_API_KEY"),
+		WeatherProvider: os.Getenv("WEATHER_PROVIDER"),
+		RedisHost:       os.Getenv("REDIS_HOST"),
+		RedisPort:       os.Getenv("REDIS_PORT"),
+		Port:            os.Getenv("PORT"),
+		Environment:     os.Getenv("ENVIRONMENT"),
 	}
 
 	// Log configuration (excluding sensitive data)
@@ -58,6 +61,7 @@ func init() {
 	log.Printf("PORT: %s", config.Port)
 	log.Printf("ENVIRONMENT: %s", config.Environment)
 	log.Printf("WEATHER_API_KEY length: %d", len(config.WeatherAPIKey))
+	log.Printf("WEATHER_PROVIDER: %s", config.WeatherProvider)
 
 	// Set default values
 	if config.RedisHost == "" {
@@ -77,23 +81,13 @@ func init() {
 		log.Printf("Using default environment: development")
 	}
 
-	log.Printf("Connecting to Redis at %s:%s...", config.RedisHost, config.RedisPort)
-
-	// Initialize Redis client with retry logic
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:            fmt.Sprintf("%s:%s", config.RedisHost, config.RedisPort),
-		Password:        "",
-		DB:              0,
-		MaxRetries:      5,
-		MinRetryBackoff: time.Second,
-		MaxRetryBackoff: time.Second * 5,
-	})
-
-	// Test Redis connection with retry
-	ctx := context.Background()
+	// Initialize the Redis client with retry logic, in standalone,
+	// Sentinel, or Cluster mode depending on REDIS_MODE.
+	redisCfg := loadRedisConfig(config)
+	var client rueidis.Client
 	var err error
 	for i := 0; i < 5; i++ {
-		_, err = redisClient.Ping(ctx).Result()
+		client, err = newRedisClient(redisCfg)
 		if err == nil {
 			log.Printf("Successfully connected to Redis")
 			break
@@ -102,8 +96,17 @@ func init() {
 		time.Sleep(time.Second * time.Duration(i+1))
 	}
 	if err != nil {
-		log.Printf("Warning: Could not establish initial Redis connection: %v", err)
+		log.Printf("Warning: Could not establish initial Redis connection, continuing in degraded mode: %v", err)
+		client = nil
+	} else {
+		client = instrumentedRedisClient(client)
 	}
+	redisClient = client
+
+	weatherCache = newWeatherCache(client)
+	weather = newWeatherProvider(config)
+
+	go reconnectRedisLoop(redisCfg)
 }
 
 func main() {
@@ -111,12 +114,20 @@ func main() {
 
 	// API routes
 	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/weather/nearby", nearbyWeatherHandler).Methods("GET")
 	api.HandleFunc("/weather/{location}", getWeatherHandler).Methods("GET")
+	api.HandleFunc("/weather/{location}/stream", weatherStreamHandler).Methods("GET")
+	api.HandleFunc("/locations", registerLocationHandler).Methods("POST")
 	api.HandleFunc("/cache/stats", getRedisCacheStats).Methods("GET")
+	api.HandleFunc("/cache/clientside/stats", getClientSideCacheStats).Methods("GET")
 	api.HandleFunc("/cache/{key}", getRedisKey).Methods("GET")
 	api.HandleFunc("/cache", listRedisKeys).Methods("GET")
 	api.HandleFunc("/health", healthCheckHandler).Methods("GET")
 
+	// Observability endpoints
+	r.Handle("/metrics", metricsHandler)
+	r.Handle("/debug/vars", http.DefaultServeMux)
+
 	// CORS middleware
 	r.Use(corsMiddleware)
 
@@ -128,7 +139,7 @@ func main() {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 		if r.Method == "OPTIONS" {
@@ -148,7 +159,8 @@ func getWeatherHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Try to get cached data
 	ctx := r.Context()
-	cachedData, err := redisClient.Get(ctx, location).Result()
+	cachedData, err := weatherCache.Get(ctx, location)
+	recordCacheResult(location, err == nil)
 	if err == nil {
 		log.Printf("Cache hit for location: %s", location)
 		w.Header().Set("Content-Type", "application/json")
@@ -158,7 +170,7 @@ func getWeatherHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Cache miss for location: %s, error: %v", location, err)
 
 	// If not in cache, fetch from weather API
-	weatherData, err := fetchWeatherData(location)
+	weatherData, err := weather.FetchWeather(ctx, location)
 	if err != nil {
 		log.Printf("Error fetching weather data for %s: %v", location, err)
 		http.Error(w, "Error fetching weather data", http.StatusInternalServerError)
@@ -174,8 +186,7 @@ func getWeatherHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set cache with 5-minute expiration
-	err = redisClient.Set(ctx, location, jsonData, 5*time.Minute).Err()
-	if err != nil {
+	if err := weatherCache.Put(ctx, location, jsonData, 5*time.Minute); err != nil {
 		log.Printf("Error caching weather data: %v", err)
 		// Continue even if caching fails
 	} else {
@@ -186,19 +197,6 @@ func getWeatherHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonData)
 }
 
-func fetchWeatherData(location string) (*WeatherData, error) {
-	// Mock implementation - replace with actual API call
-	// Using random variations for demo purposes
-	return &WeatherData{
-		Temperature: 22.5 + float64(time.Now().UnixNano()%5),
-		Humidity:    65.0 + float64(time.Now().UnixNano()%10),
-		WindSpeed:   12.0 + float64(time.Now().UnixNano()%8),
-		Description: "Partly cloudy",
-		Location:    location,
-		Timestamp:   time.Now().Format(time.RFC3339),
-	}, nil
-}
-
 func getRedisCacheStats(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	stats := struct {
@@ -210,24 +208,25 @@ func getRedisCacheStats(w http.ResponseWriter, r *http.Request) {
 		Data: make(map[string]WeatherData),
 	}
 
-	// Get all keys
-	iter := redisClient.Scan(ctx, 0, "*", 0).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
+	client := currentRedisClient()
+	keys, err := scanAllKeysByShard(ctx, client, weatherCacheKeyPrefix+"*")
+	if err != nil {
+		log.Printf("Error scanning Redis: %v", err)
+	}
+
+	for _, k := range keys {
 		stats.TotalKeys++
 
-		// Get TTL for the key
-		ttl, err := redisClient.TTL(ctx, key).Result()
-		if err == nil && ttl.Seconds() > 0 {
+		ttl, err := client.Do(ctx, client.B().Ttl().Key(k.Key).Build()).AsInt64()
+		if err == nil && ttl > 0 {
 			stats.KeysWithTTL++
 		}
 
-		// Get the actual data
-		val, err := redisClient.Get(ctx, key).Result()
+		val, err := client.Do(ctx, client.B().Get().Key(k.Key).Build()).ToString()
 		if err == nil {
 			var weatherData WeatherData
 			if err := json.Unmarshal([]byte(val), &weatherData); err == nil {
-				stats.Data[key] = weatherData
+				stats.Data[k.Key] = weatherData
 				stats.CachedLocations = append(stats.CachedLocations, weatherData.Location)
 			}
 		}
@@ -237,12 +236,38 @@ func getRedisCacheStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// getClientSideCacheStats reports rueidis client-side (RESP3 tracking)
+// cache hit/miss counters, so operators can confirm the cache is actually
+// cutting round-trips for hot locations.
+func getClientSideCacheStats(w http.ResponseWriter, r *http.Request) {
+	hits, misses := weatherCache.Stats()
+
+	response := struct {
+		Hits    uint64  `json:"hits"`
+		Misses  uint64  `json:"misses"`
+		HitRate float64 `json:"hitRate"`
+	}{Hits: hits, Misses: misses}
+
+	if total := hits + misses; total > 0 {
+		response.HitRate = float64(hits) / float64(total)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 func getRedisKey(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	key := vars["key"]
 	ctx := context.Background()
 
-	val, err := redisClient.Get(ctx, key).Result()
+	client := currentRedisClient()
+	if client == nil {
+		http.Error(w, "Redis unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	val, err := client.Do(ctx, client.B().Get().Key(key).Build()).ToString()
 	if err != nil {
 		http.Error(w, "Key not found", http.StatusNotFound)
 		return
@@ -258,13 +283,19 @@ func getRedisKey(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(weatherData)
 }
 
+// keyLocation identifies which shard a cached key lives on, so operators
+// running under Redis Cluster can tell which master served a given key.
+type keyLocation struct {
+	Key   string `json:"key"`
+	Shard string `json:"shard"`
+}
+
 func listRedisKeys(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
-	var keys []string
 
-	iter := redisClient.Scan(ctx, 0, "*", 0).Iterator()
-	for iter.Next(ctx) {
-		keys = append(keys, iter.Val())
+	keys, err := scanAllKeysByShard(ctx, currentRedisClient(), "*")
+	if err != nil {
+		log.Printf("Error listing Redis keys: %v", err)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -279,8 +310,11 @@ func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	redisStatus := "connected"
 
 	// Check Redis connection
-	_, err := redisClient.Ping(ctx).Result()
-	if err != nil {
+	client := currentRedisClient()
+	if client == nil {
+		status = "degraded"
+		redisStatus = "disconnected"
+	} else if err := client.Do(ctx, client.B().Ping().Build()).Error(); err != nil {
 		log.Printf("Health check Redis ping failed: %v", err)
 		status = "degraded"
 		redisStatus = "disconnected"
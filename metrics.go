@@ -0,0 +1,133 @@
+// metrics.go
+package main
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/rueidis"
+	"github.com/redis/rueidis/rueidishook"
+)
+
+var (
+	cacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_cache_requests_total",
+		Help: "Count of weather cache lookups by location and result (hit/miss).",
+	}, []string{"location", "result"})
+
+	providerLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "weather_provider_request_duration_seconds",
+		Help:    "Latency of upstream weather provider calls.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	providerErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_provider_errors_total",
+		Help: "Count of upstream weather provider errors by type.",
+	}, []string{"provider", "error_type"})
+
+	redisCommandLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "weather_redis_command_duration_seconds",
+		Help:    "Latency of Redis commands issued by the app.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"command"})
+
+	streamSubscribersGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "weather_stream_subscribers",
+		Help: "Current number of connected SSE subscribers across all locations.",
+	})
+)
+
+// expvar counters mirror a subset of the Prometheus metrics above for
+// operators who scrape /debug/vars instead of /metrics.
+var (
+	expvarCacheHits   = expvar.NewInt("weatherCacheHits")
+	expvarCacheMisses = expvar.NewInt("weatherCacheMisses")
+	expvarSubscribers = expvar.NewInt("weatherStreamSubscribers")
+)
+
+// metricsHandler serves Prometheus metrics for the /metrics endpoint.
+var metricsHandler = promhttp.Handler()
+
+func recordCacheResult(location string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+		expvarCacheHits.Add(1)
+	} else {
+		expvarCacheMisses.Add(1)
+	}
+	cacheRequestsTotal.WithLabelValues(location, result).Inc()
+}
+
+func recordProviderLatency(provider string, d time.Duration) {
+	providerLatencySeconds.WithLabelValues(provider).Observe(d.Seconds())
+}
+
+func recordProviderError(provider, errType string) {
+	providerErrorsTotal.WithLabelValues(provider, errType).Inc()
+}
+
+func adjustSubscriberCount(delta int) {
+	streamSubscribersGauge.Add(float64(delta))
+	expvarSubscribers.Add(int64(delta))
+}
+
+// instrumentedRedisClient wraps client so every command's latency is
+// observed into redisCommandLatencySeconds, except pub/sub subscriptions
+// made through Receive, which are passed through untimed.
+func instrumentedRedisClient(client rueidis.Client) rueidis.Client {
+	return rueidishook.WithHook(client, redisMetricsHook{})
+}
+
+type redisMetricsHook struct{}
+
+func (redisMetricsHook) Do(client rueidis.Client, ctx context.Context, cmd rueidis.Completed) rueidis.RedisResult {
+	start := time.Now()
+	resp := client.Do(ctx, cmd)
+	redisCommandLatencySeconds.WithLabelValues(cmd.Commands()[0]).Observe(time.Since(start).Seconds())
+	return resp
+}
+
+func (redisMetricsHook) DoCache(client rueidis.Client, ctx context.Context, cmd rueidis.Cacheable, ttl time.Duration) rueidis.RedisResult {
+	start := time.Now()
+	resp := client.DoCache(ctx, cmd, ttl)
+	redisCommandLatencySeconds.WithLabelValues(cmd.Commands()[0]).Observe(time.Since(start).Seconds())
+	return resp
+}
+
+func (redisMetricsHook) DoMulti(client rueidis.Client, ctx context.Context, multi ...rueidis.Completed) []rueidis.RedisResult {
+	start := time.Now()
+	resp := client.DoMulti(ctx, multi...)
+	redisCommandLatencySeconds.WithLabelValues("multi").Observe(time.Since(start).Seconds())
+	return resp
+}
+
+func (redisMetricsHook) DoMultiCache(client rueidis.Client, ctx context.Context, multi ...rueidis.CacheableTTL) []rueidis.RedisResult {
+	start := time.Now()
+	resp := client.DoMultiCache(ctx, multi...)
+	redisCommandLatencySeconds.WithLabelValues("multi_cache").Observe(time.Since(start).Seconds())
+	return resp
+}
+
+func (redisMetricsHook) Receive(client rueidis.Client, ctx context.Context, subscribe rueidis.Completed, fn func(msg rueidis.PubSubMessage)) error {
+	return client.Receive(ctx, subscribe, fn)
+}
+
+func (redisMetricsHook) DoStream(client rueidis.Client, ctx context.Context, cmd rueidis.Completed) rueidis.RedisResultStream {
+	start := time.Now()
+	resp := client.DoStream(ctx, cmd)
+	redisCommandLatencySeconds.WithLabelValues(cmd.Commands()[0]).Observe(time.Since(start).Seconds())
+	return resp
+}
+
+func (redisMetricsHook) DoMultiStream(client rueidis.Client, ctx context.Context, multi ...rueidis.Completed) rueidis.MultiRedisResultStream {
+	start := time.Now()
+	resp := client.DoMultiStream(ctx, multi...)
+	redisCommandLatencySeconds.WithLabelValues("multi_stream").Observe(time.Since(start).Seconds())
+	return resp
+}
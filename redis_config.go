@@ -0,0 +1,189 @@
+// redis_config.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+// errRedisUnavailable is returned in place of a Redis call whenever the
+// client could not be established at startup, so callers degrade (cache
+// miss, "degraded" health, HTTP 503) instead of dereferencing a nil
+// client.
+var errRedisUnavailable = errors.New("redis client unavailable")
+
+// redisReconnectInterval sets how often reconnectRedisLoop retries
+// newRedisClient while the service is running in degraded mode.
+const redisReconnectInterval = 10 * time.Second
+
+// redisMu guards redisClient, since reconnectRedisLoop can install a live
+// client from a background goroutine while request handlers are reading
+// it concurrently.
+var redisMu sync.RWMutex
+
+// currentRedisClient returns the live Redis client, or nil if the service
+// is currently running in degraded mode.
+func currentRedisClient() rueidis.Client {
+	redisMu.RLock()
+	defer redisMu.RUnlock()
+	return redisClient
+}
+
+// setRedisClient installs client (possibly nil) as the active Redis
+// client and keeps weatherCache in sync, so lookups start using a newly
+// (re)established connection immediately instead of a stale nil.
+func setRedisClient(client rueidis.Client) {
+	redisMu.Lock()
+	redisClient = client
+	redisMu.Unlock()
+	if weatherCache != nil {
+		weatherCache.setClient(client)
+	}
+}
+
+// reconnectRedisLoop retries newRedisClient on redisReconnectInterval
+// until it succeeds, then installs the live client. It runs for the life
+// of the process so a replica that raced Redis at startup (e.g. a
+// Sentinel failover or StatefulSet ordering hiccup) recovers on its own
+// instead of staying in degraded mode until it is killed and restarted.
+func reconnectRedisLoop(rc RedisConfig) {
+	ticker := time.NewTicker(redisReconnectInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if currentRedisClient() != nil {
+			continue
+		}
+
+		client, err := newRedisClient(rc)
+		if err != nil {
+			log.Printf("Redis reconnect attempt failed: %v", err)
+			continue
+		}
+
+		log.Printf("Reconnected to Redis")
+		setRedisClient(instrumentedRedisClient(client))
+	}
+}
+
+// RedisConfig holds the settings needed to construct a Redis client for any
+// of the supported topologies: standalone, Sentinel, or Cluster.
+type RedisConfig struct {
+	Mode             string
+	Addr             string
+	ClusterAddrs     []string
+	SentinelAddrs    []string
+	SentinelMaster   string
+	SentinelPassword string
+	Password         string
+	DB               int
+}
+
+// loadRedisConfig reads the Redis topology settings from the environment,
+// falling back to the standalone host/port already present in Config.
+func loadRedisConfig(cfg Config) RedisConfig {
+	rc := RedisConfig{
+		Mode:             strings.ToLower(os.Getenv("REDIS_MODE")),
+		Addr:             fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
+		SentinelMaster:   os.Getenv("REDIS_SENTINEL_MASTER"),
+		SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+		Password:         os.Getenv("REDIS_PASSWORD"),
+	}
+
+	if rc.Mode == "" {
+		rc.Mode = "standalone"
+	}
+	if addrs := os.Getenv("REDIS_SENTINEL_ADDRS"); addrs != "" {
+		rc.SentinelAddrs = strings.Split(addrs, ",")
+	}
+	if addrs := os.Getenv("REDIS_CLUSTER_ADDRS"); addrs != "" {
+		rc.ClusterAddrs = strings.Split(addrs, ",")
+	}
+	if v := os.Getenv("REDIS_DB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			rc.DB = n
+		}
+	}
+
+	// REDIS_MAX_IDLE/REDIS_MAX_ACTIVE sized the old go-redis connection
+	// pool. rueidis multiplexes commands over a small fixed number of
+	// connections per node instead of pooling one-connection-per-command,
+	// so there is no equivalent knob; warn rather than silently ignoring
+	// operator-set values carried over from a pre-migration deployment.
+	if os.Getenv("REDIS_MAX_IDLE") != "" || os.Getenv("REDIS_MAX_ACTIVE") != "" {
+		log.Printf("Warning: REDIS_MAX_IDLE/REDIS_MAX_ACTIVE are no longer honored; rueidis multiplexes commands over a fixed connection set instead of pooling")
+	}
+
+	return rc
+}
+
+// newRedisClient builds a rueidis.Client for the configured topology.
+// rueidis detects Cluster vs standalone itself once connected, so the
+// modes mainly differ in which addresses and Sentinel options are given;
+// standalone pins ForceSingleClient so a lone node is never mistaken for
+// the start of a cluster.
+func newRedisClient(rc RedisConfig) (rueidis.Client, error) {
+	opt := rueidis.ClientOption{
+		Password: rc.Password,
+		SelectDB: rc.DB,
+	}
+
+	switch rc.Mode {
+	case "sentinel":
+		log.Printf("Connecting to Redis via Sentinel (master=%s, sentinels=%v)", rc.SentinelMaster, rc.SentinelAddrs)
+		opt.InitAddress = rc.SentinelAddrs
+		opt.Sentinel = rueidis.SentinelOption{
+			MasterSet: rc.SentinelMaster,
+			Password:  rc.SentinelPassword,
+		}
+	case "cluster":
+		log.Printf("Connecting to Redis Cluster (addrs=%v)", rc.ClusterAddrs)
+		opt.InitAddress = rc.ClusterAddrs
+	default:
+		log.Printf("Connecting to standalone Redis at %s", rc.Addr)
+		opt.InitAddress = []string{rc.Addr}
+		opt.ForceSingleClient = true
+	}
+
+	return rueidis.NewClient(opt)
+}
+
+// scanAllKeysByShard runs a SCAN matching pattern against every node the
+// client knows about (a single node in standalone/Sentinel mode, every
+// master in Cluster mode), recording which node served each key.
+func scanAllKeysByShard(ctx context.Context, client rueidis.Client, pattern string) ([]keyLocation, error) {
+	if client == nil {
+		return nil, errRedisUnavailable
+	}
+
+	var keys []keyLocation
+
+	for addr, node := range client.Nodes() {
+		cursor := uint64(0)
+		for {
+			cmd := node.B().Scan().Cursor(cursor).Match(pattern).Build()
+			entry, err := node.Do(ctx, cmd).AsScanEntry()
+			if err != nil {
+				return nil, fmt.Errorf("scanning shard %s: %w", addr, err)
+			}
+			for _, key := range entry.Elements {
+				keys = append(keys, keyLocation{Key: key, Shard: addr})
+			}
+			cursor = entry.Cursor
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+
+	return keys, nil
+}
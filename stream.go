@@ -0,0 +1,310 @@
+// stream.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/redis/rueidis"
+)
+
+// streamPollInterval controls how often the elected poller refreshes a
+// streamed location, configurable via STREAM_POLL_INTERVAL_SECONDS.
+func streamPollInterval() time.Duration {
+	if v := os.Getenv("STREAM_POLL_INTERVAL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 30 * time.Second
+}
+
+// pollLockTTL sizes the per-location poll lock relative to the poll
+// interval: long enough that the leader's own refresh (every tick) always
+// lands well before expiry, short enough that a crashed leader's lock
+// lapses within a few missed ticks instead of stalling the location.
+func pollLockTTL(interval time.Duration) time.Duration {
+	return interval * 3
+}
+
+// pollLockAcquireScript atomically acquires the lock if it is unheld, or
+// refreshes its TTL if this replica already holds it (identified by
+// token), so the same leader keeps polling on every tick instead of
+// losing the lock to its own un-expired key.
+const pollLockAcquireScript = `
+local cur = redis.call('GET', KEYS[1])
+if cur == false or cur == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0
+`
+
+// pollLockReleaseScript releases the lock only if this replica still
+// holds it, so a replica never deletes a lock another replica has since
+// won.
+const pollLockReleaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+func weatherUpdatesChannel(location string) string {
+	return "weather:updates:" + location
+}
+
+func weatherPollLockKey(location string) string {
+	return "weather:poll-lock:" + location
+}
+
+// locationHub fans weather updates for a single location out to every
+// client subscribed to it on this replica. It also subscribes to the
+// location's Redis pub/sub channel, so updates published by whichever
+// replica is currently polling the upstream provider reach every replica's
+// subscribers.
+type locationHub struct {
+	location    string
+	leaderToken string
+	mu          sync.Mutex
+	subscribers map[string]chan []byte
+	cancel      context.CancelFunc
+}
+
+// streamHub owns one locationHub per location with at least one active
+// subscriber on this replica.
+type streamHub struct {
+	mu   sync.Mutex
+	hubs map[string]*locationHub
+}
+
+var globalStreamHub = &streamHub{hubs: make(map[string]*locationHub)}
+
+// subscribe registers clientID for location updates, starting the
+// location's background poller/subscriber goroutine on the first
+// subscriber.
+func (h *streamHub) subscribe(location, clientID string) chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hub, ok := h.hubs[location]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		hub = &locationHub{
+			location:    location,
+			leaderToken: fmt.Sprintf("%s-%d", location, time.Now().UnixNano()),
+			subscribers: make(map[string]chan []byte),
+			cancel:      cancel,
+		}
+		h.hubs[location] = hub
+		go hub.run(ctx)
+	}
+
+	ch := make(chan []byte, 4)
+	hub.mu.Lock()
+	hub.subscribers[clientID] = ch
+	hub.mu.Unlock()
+	adjustSubscriberCount(1)
+
+	return ch
+}
+
+// unsubscribe removes clientID from location, stopping the background
+// goroutine once the last subscriber has left.
+func (h *streamHub) unsubscribe(location, clientID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hub, ok := h.hubs[location]
+	if !ok {
+		return
+	}
+
+	hub.mu.Lock()
+	delete(hub.subscribers, clientID)
+	empty := len(hub.subscribers) == 0
+	hub.mu.Unlock()
+	adjustSubscriberCount(-1)
+
+	if empty {
+		hub.cancel()
+		delete(h.hubs, location)
+	}
+}
+
+func (h *locationHub) broadcast(data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for clientID, ch := range h.subscribers {
+		select {
+		case ch <- data:
+		default:
+			log.Printf("Dropping weather update for slow stream client %s on %s", clientID, h.location)
+		}
+	}
+}
+
+// run subscribes to this location's Redis pub/sub channel for the life of
+// the hub, and polls the upstream provider on an interval while it holds
+// the per-location poll lock, releasing the lock on the way out.
+func (h *locationHub) run(ctx context.Context) {
+	go h.subscribeRedis(ctx)
+
+	interval := streamPollInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer h.releaseLock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.pollIfLeader(ctx, interval)
+		}
+	}
+}
+
+// pollIfLeader fetches fresh weather data and publishes it, but only if
+// this replica currently holds the per-location poll lock, so a fleet of
+// replicas behind the same Kubernetes service doesn't hammer the upstream
+// API in lockstep. Every tick either acquires the lock for the first time
+// or refreshes the TTL on the lock this replica already holds, so a
+// healthy leader keeps polling on the configured interval instead of
+// losing the lock to its own un-expired key.
+func (h *locationHub) pollIfLeader(ctx context.Context, interval time.Duration) {
+	client := currentRedisClient()
+	if client == nil {
+		return
+	}
+
+	leader, err := h.acquireOrRefreshLock(ctx, client, pollLockTTL(interval))
+	if err != nil {
+		log.Printf("Error acquiring poll lock for %s: %v", h.location, err)
+		return
+	}
+	if !leader {
+		return
+	}
+
+	weatherData, err := weather.FetchWeather(ctx, h.location)
+	if err != nil {
+		log.Printf("Error polling weather for stream %s: %v", h.location, err)
+		return
+	}
+
+	jsonData, err := json.Marshal(weatherData)
+	if err != nil {
+		log.Printf("Error marshaling streamed weather data for %s: %v", h.location, err)
+		return
+	}
+
+	if err := weatherCache.Put(ctx, h.location, jsonData, 5*time.Minute); err != nil {
+		log.Printf("Error caching streamed weather data for %s: %v", h.location, err)
+	}
+
+	channel := weatherUpdatesChannel(h.location)
+	if err := client.Do(ctx, client.B().Publish().Channel(channel).Message(string(jsonData)).Build()).Error(); err != nil {
+		log.Printf("Error publishing weather update for %s: %v", h.location, err)
+	}
+}
+
+// acquireOrRefreshLock runs pollLockAcquireScript, returning whether this
+// replica holds the per-location poll lock after the call.
+func (h *locationHub) acquireOrRefreshLock(ctx context.Context, client rueidis.Client, ttl time.Duration) (bool, error) {
+	cmd := client.B().Eval().Script(pollLockAcquireScript).Numkeys(1).
+		Key(weatherPollLockKey(h.location)).
+		Arg(h.leaderToken, strconv.FormatInt(ttl.Milliseconds(), 10)).Build()
+
+	acquired, err := client.Do(ctx, cmd).AsInt64()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// releaseLock drops the poll lock if this replica still holds it, so the
+// next replica to take a subscriber for this location can become leader
+// immediately instead of waiting out the TTL.
+func (h *locationHub) releaseLock() {
+	client := currentRedisClient()
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	cmd := client.B().Eval().Script(pollLockReleaseScript).Numkeys(1).
+		Key(weatherPollLockKey(h.location)).Arg(h.leaderToken).Build()
+	if err := client.Do(ctx, cmd).Error(); err != nil {
+		log.Printf("Error releasing poll lock for %s: %v", h.location, err)
+	}
+}
+
+// subscribeRedis relays this location's pub/sub channel to broadcast for
+// as long as ctx is alive, reconnecting on transient subscription errors.
+func (h *locationHub) subscribeRedis(ctx context.Context) {
+	channel := weatherUpdatesChannel(h.location)
+	for ctx.Err() == nil {
+		client := currentRedisClient()
+		if client == nil {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		err := client.Receive(ctx, client.B().Subscribe().Channel(channel).Build(), func(msg rueidis.PubSubMessage) {
+			h.broadcast([]byte(msg.Message))
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Redis subscription for %s dropped: %v, retrying", channel, err)
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// weatherStreamHandler upgrades the response to text/event-stream and
+// pushes a new WeatherData frame every time the location's poller
+// publishes fresh data, until the client disconnects.
+func weatherStreamHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	location := vars["location"]
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	clientID := fmt.Sprintf("%s-%d", r.RemoteAddr, time.Now().UnixNano())
+	updates := globalStreamHub.subscribe(location, clientID)
+	defer globalStreamHub.unsubscribe(location, clientID)
+
+	log.Printf("Client %s subscribed to weather stream for %s", clientID, location)
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Client %s disconnected from weather stream for %s", clientID, location)
+			return
+		case data := <-updates:
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// weather_cache.go
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+// weatherCacheKeyPrefix namespaces the weather cache entries this service
+// writes to Redis, versioned so the format can change without colliding
+// with old entries. The geo index and stream poll-lock keys do not use it.
+const weatherCacheKeyPrefix = "weather:v1:"
+
+func weatherCacheKey(location string) string {
+	return weatherCacheKeyPrefix + location
+}
+
+// WeatherCache wraps a rueidis.Client, using RESP3 client-side caching
+// (tracking) to keep a local copy of weather JSON for hot locations and
+// cut round-trips to Redis for repeat lookups.
+type WeatherCache struct {
+	mu     sync.RWMutex
+	client rueidis.Client
+	hits   uint64
+	misses uint64
+}
+
+func newWeatherCache(client rueidis.Client) *WeatherCache {
+	return &WeatherCache{client: client}
+}
+
+// setClient swaps in client (possibly nil), so a cache constructed while
+// Redis was unreachable starts using it once reconnectRedisLoop installs
+// a live connection.
+func (c *WeatherCache) setClient(client rueidis.Client) {
+	c.mu.Lock()
+	c.client = client
+	c.mu.Unlock()
+}
+
+func (c *WeatherCache) currentClient() rueidis.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+// Get returns the cached weather JSON for location, if any. Lookups are
+// served from the local client-side cache whenever Redis has not
+// invalidated the key, falling back to a round-trip on a miss.
+func (c *WeatherCache) Get(ctx context.Context, location string) (string, error) {
+	client := c.currentClient()
+	if client == nil {
+		atomic.AddUint64(&c.misses, 1)
+		return "", errRedisUnavailable
+	}
+
+	cmd := client.B().Get().Key(weatherCacheKey(location)).Cache()
+	resp := client.DoCache(ctx, cmd, 5*time.Minute)
+
+	if resp.IsCacheHit() {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+
+	return resp.ToString()
+}
+
+// Put stores weather JSON for location with the given TTL.
+func (c *WeatherCache) Put(ctx context.Context, location string, data []byte, ttl time.Duration) error {
+	client := c.currentClient()
+	if client == nil {
+		return errRedisUnavailable
+	}
+	cmd := client.B().Setex().Key(weatherCacheKey(location)).Seconds(int64(ttl.Seconds())).Value(string(data)).Build()
+	return client.Do(ctx, cmd).Error()
+}
+
+// Stats reports client-side cache hit/miss counters.
+func (c *WeatherCache) Stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+}
@@ -0,0 +1,237 @@
+// weather_provider.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// WeatherProvider fetches current weather conditions for a named location.
+type WeatherProvider interface {
+	FetchWeather(ctx context.Context, location string) (*WeatherData, error)
+}
+
+// newWeatherProvider selects and constructs a WeatherProvider based on the
+// WEATHER_PROVIDER environment variable, wrapping it with a per-provider
+// rate limiter and request collapsing.
+func newWeatherProvider(cfg Config) WeatherProvider {
+	var provider WeatherProvider
+	name := cfg.WeatherProvider
+
+	switch cfg.WeatherProvider {
+	case "openweathermap":
+		provider = &openWeatherMapProvider{
+			apiKey: cfg.WeatherAPIKey,
+			client: newProviderHTTPClient(),
+		}
+	case "weatherapi":
+		provider = &weatherAPIProvider{
+			apiKey: cfg.WeatherAPIKey,
+			client: newProviderHTTPClient(),
+		}
+	case "mock", "":
+		name = "mock"
+		provider = &mockProvider{}
+	default:
+		log.Printf("Unknown WEATHER_PROVIDER %q, falling back to mock provider", cfg.WeatherProvider)
+		name = "mock"
+		provider = &mockProvider{}
+	}
+
+	log.Printf("Using weather provider: %s", name)
+
+	return &rateLimitedProvider{
+		next:    provider,
+		limiter: rate.NewLimiter(rate.Limit(5), 10),
+		group:   new(singleflight.Group),
+		name:    name,
+	}
+}
+
+func newProviderHTTPClient() *http.Client {
+	return &http.Client{Timeout: 5 * time.Second}
+}
+
+// rateLimitedProvider wraps a WeatherProvider with a token-bucket rate
+// limiter and collapses concurrent requests for the same location into a
+// single upstream call.
+type rateLimitedProvider struct {
+	next    WeatherProvider
+	limiter *rate.Limiter
+	group   *singleflight.Group
+	name    string
+}
+
+func (p *rateLimitedProvider) FetchWeather(ctx context.Context, location string) (*WeatherData, error) {
+	start := time.Now()
+	v, err, _ := p.group.Do(location, func() (interface{}, error) {
+		if err := p.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limiter: %w", err)
+		}
+		return p.next.FetchWeather(ctx, location)
+	})
+	recordProviderLatency(p.name, time.Since(start))
+	if err != nil {
+		recordProviderError(p.name, classifyProviderError(err))
+		return nil, err
+	}
+	return v.(*WeatherData), nil
+}
+
+// classifyProviderError buckets provider errors into coarse types suitable
+// for a low-cardinality Prometheus label.
+func classifyProviderError(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "upstream"
+	}
+}
+
+// mockProvider generates synthetic weather data without calling out to any
+// upstream API. It is the default provider and is useful for local
+// development and tests.
+type mockProvider struct{}
+
+func (p *mockProvider) FetchWeather(ctx context.Context, location string) (*WeatherData, error) {
+	return &WeatherData{
+		Temperature: 22.5 + float64(time.Now().UnixNano()%5),
+		Humidity:    65.0 + float64(time.Now().UnixNano()%10),
+		WindSpeed:   12.0 + float64(time.Now().UnixNano()%8),
+		Description: "Partly cloudy",
+		Location:    location,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// openWeatherMapProvider fetches weather data from the OpenWeatherMap
+// current weather API.
+type openWeatherMapProvider struct {
+	apiKey string
+	client *http.Client
+}
+
+type openWeatherMapResponse struct {
+	Main struct {
+		Temp     float64 `json:"temp"`
+		Humidity float64 `json:"humidity"`
+	} `json:"main"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+	} `json:"wind"`
+	Weather []struct {
+		Description string `json:"description"`
+	} `json:"weather"`
+	Name string `json:"name"`
+}
+
+func (p *openWeatherMapProvider) FetchWeather(ctx context.Context, location string) (*WeatherData, error) {
+	url := fmt.Sprintf("https://api.openweathermap.org/data/2.5/weather?q=%s&units=metric&appid=%s",
+		neturl.QueryEscape(location), neturl.QueryEscape(p.apiKey))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building openweathermap request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling openweathermap: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading openweathermap response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openweathermap returned %d: %s", resp.StatusCode, body)
+	}
+
+	var parsed openWeatherMapResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing openweathermap response: %w", err)
+	}
+
+	description := ""
+	if len(parsed.Weather) > 0 {
+		description = parsed.Weather[0].Description
+	}
+
+	return &WeatherData{
+		Temperature: parsed.Main.Temp,
+		Humidity:    parsed.Main.Humidity,
+		WindSpeed:   parsed.Wind.Speed,
+		Description: description,
+		Location:    location,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// weatherAPIProvider fetches weather data from WeatherAPI.com.
+type weatherAPIProvider struct {
+	apiKey string
+	client *http.Client
+}
+
+type weatherAPIResponse struct {
+	Current struct {
+		TempC     float64 `json:"temp_c"`
+		Humidity  float64 `json:"humidity"`
+		WindKph   float64 `json:"wind_kph"`
+		Condition struct {
+			Text string `json:"text"`
+		} `json:"condition"`
+	} `json:"current"`
+}
+
+func (p *weatherAPIProvider) FetchWeather(ctx context.Context, location string) (*WeatherData, error) {
+	url := fmt.Sprintf("https://api.weatherapi.com/v1/current.json?key=%s&q=%s",
+		neturl.QueryEscape(p.apiKey), neturl.QueryEscape(location))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building weatherapi request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling weatherapi: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading weatherapi response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("weatherapi returned %d: %s", resp.StatusCode, body)
+	}
+
+	var parsed weatherAPIResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing weatherapi response: %w", err)
+	}
+
+	return &WeatherData{
+		Temperature: parsed.Current.TempC,
+		Humidity:    parsed.Current.Humidity,
+		WindSpeed:   parsed.Current.WindKph,
+		Description: parsed.Current.Condition.Text,
+		Location:    location,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,126 @@
+// weather_provider_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// countingProvider counts how many times FetchWeather actually ran
+// upstream, so tests can tell singleflight collapsing apart from the
+// caller simply retrying.
+type countingProvider struct {
+	calls atomic.Int64
+	delay time.Duration
+}
+
+func (p *countingProvider) FetchWeather(ctx context.Context, location string) (*WeatherData, error) {
+	p.calls.Add(1)
+	if p.delay > 0 {
+		time.Sleep(p.delay)
+	}
+	return &WeatherData{Location: location}, nil
+}
+
+func newTestRateLimitedProvider(next WeatherProvider, limit rate.Limit, burst int) *rateLimitedProvider {
+	return &rateLimitedProvider{
+		next:    next,
+		limiter: rate.NewLimiter(limit, burst),
+		group:   new(singleflight.Group),
+		name:    "test",
+	}
+}
+
+func TestRateLimitedProviderCollapsesConcurrentRequests(t *testing.T) {
+	next := &countingProvider{delay: 50 * time.Millisecond}
+	p := newTestRateLimitedProvider(next, rate.Inf, 0)
+
+	const concurrent = 10
+	var wg sync.WaitGroup
+	wg.Add(concurrent)
+	for i := 0; i < concurrent; i++ {
+		go func() {
+			defer wg.Done()
+			if _, err := p.FetchWeather(context.Background(), "london"); err != nil {
+				t.Errorf("FetchWeather: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := next.calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 1 (concurrent requests for the same location should collapse)", got)
+	}
+}
+
+func TestRateLimitedProviderDoesNotCollapseDistinctLocations(t *testing.T) {
+	next := &countingProvider{}
+	p := newTestRateLimitedProvider(next, rate.Inf, 0)
+
+	for _, location := range []string{"london", "paris"} {
+		if _, err := p.FetchWeather(context.Background(), location); err != nil {
+			t.Fatalf("FetchWeather(%s): %v", location, err)
+		}
+	}
+
+	if got := next.calls.Load(); got != 2 {
+		t.Errorf("upstream calls = %d, want 2 (distinct locations must not collapse into one request)", got)
+	}
+}
+
+func TestRateLimitedProviderAppliesLimiter(t *testing.T) {
+	next := &countingProvider{}
+	// One token up front, refilling every 50ms: the second distinct
+	// location has to wait for a refill instead of sailing through.
+	p := newTestRateLimitedProvider(next, rate.Every(50*time.Millisecond), 1)
+
+	start := time.Now()
+	if _, err := p.FetchWeather(context.Background(), "london"); err != nil {
+		t.Fatalf("FetchWeather(london): %v", err)
+	}
+	if _, err := p.FetchWeather(context.Background(), "paris"); err != nil {
+		t.Fatalf("FetchWeather(paris): %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("elapsed = %v, want the limiter to delay the second distinct-location request", elapsed)
+	}
+}
+
+func TestRateLimitedProviderReturnsLimiterError(t *testing.T) {
+	next := &countingProvider{}
+	p := newTestRateLimitedProvider(next, rate.Every(time.Hour), 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := p.FetchWeather(ctx, "london")
+	if err == nil {
+		t.Fatal("expected an error when the limiter cannot grant a token before the context deadline")
+	}
+	if next.calls.Load() != 0 {
+		t.Errorf("upstream should not be called when the rate limiter rejects the request")
+	}
+}
+
+func TestClassifyProviderError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, "timeout"},
+		{context.Canceled, "canceled"},
+		{errors.New("connection refused"), "upstream"},
+	}
+	for _, c := range cases {
+		if got := classifyProviderError(c.err); got != c.want {
+			t.Errorf("classifyProviderError(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}